@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// DebugProfile is a curated, named preset for PatchDebugContainer so
+// operators can hand users a short-list of safe images/capabilities (e.g.
+// netshoot, jvm, golang) instead of letting anyone pull an arbitrary image
+// with arbitrary privileges into a production pod. ProjectName scopes a
+// profile to a single project; an empty ProjectName marks one of the
+// built-in profiles shared across every project.
+type DebugProfile struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"            json:"id,omitempty"`
+	ProjectName string             `bson:"project_name"             json:"project_name"`
+	Name        string             `bson:"name"                     json:"name"`
+
+	Image   string   `bson:"image"                    json:"image"`
+	Command []string `bson:"command"                  json:"command"`
+	Args    []string `bson:"args"                     json:"args"`
+
+	// Capabilities are Linux capability names (e.g. SYS_PTRACE, SYS_ADMIN)
+	// granted to the debug container's security context.
+	Capabilities []string `bson:"capabilities"             json:"capabilities"`
+	Privileged   bool     `bson:"privileged"               json:"privileged"`
+
+	ShareProcessNamespace bool `bson:"share_process_namespace"  json:"share_process_namespace"`
+	// TargetContainerByDefault marks a profile that only works against a
+	// target container: PatchDebugContainer rejects it unless the caller
+	// names one via overrides.TargetContainer, and then inherits that
+	// container's volume mounts.
+	TargetContainerByDefault bool `bson:"target_container_by_default" json:"target_container_by_default"`
+
+	Description string `bson:"description"              json:"description"`
+	UpdateBy    string `bson:"update_by"                json:"update_by"`
+	CreateTime  int64  `bson:"create_time"               json:"create_time"`
+	UpdateTime  int64  `bson:"update_time"               json:"update_time"`
+}
+
+func (DebugProfile) TableName() string {
+	return "debug_profile"
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type DebugProfileColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewDebugProfileColl() *DebugProfileColl {
+	name := models.DebugProfile{}.TableName()
+	return &DebugProfileColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *DebugProfileColl) GetCollectionName() string {
+	return c.coll
+}
+
+// EnsureIndex enforces one name per project, while still letting different
+// projects (or the global "" project of built-in profiles) reuse the same
+// name.
+func (c *DebugProfileColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys:    bson.D{{Key: "project_name", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+// Find returns the profile named name for projectName, or mongo.ErrNoDocuments
+// if it doesn't exist. A project-scoped profile takes precedence; if
+// projectName has no profile of that name, the global ("") built-in profile
+// of that name is returned instead.
+func (c *DebugProfileColl) Find(projectName, name string) (*models.DebugProfile, error) {
+	profile := &models.DebugProfile{}
+	err := c.Collection.FindOne(context.Background(), bson.M{"name": name, "project_name": projectName}).Decode(profile)
+	if err == mongo.ErrNoDocuments && projectName != "" {
+		err = c.Collection.FindOne(context.Background(), bson.M{"name": name, "project_name": ""}).Decode(profile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// List returns every profile visible to projectName: the ones curated for
+// that project plus the global built-in profiles.
+func (c *DebugProfileColl) List(projectName string) ([]*models.DebugProfile, error) {
+	var profiles []*models.DebugProfile
+
+	cursor, err := c.Collection.Find(context.Background(), bson.M{
+		"project_name": bson.M{"$in": []string{"", projectName}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	if err := cursor.All(context.Background(), &profiles); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+func (c *DebugProfileColl) Create(profile *models.DebugProfile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("debug profile name cannot be empty")
+	}
+
+	now := time.Now().Unix()
+	profile.CreateTime = now
+	profile.UpdateTime = now
+
+	_, err := c.Collection.InsertOne(context.Background(), profile)
+	return err
+}
+
+// Update replaces projectName/name's mutable fields. CreateTime is
+// deliberately left out of the $set so that updating a profile never zeroes
+// its original creation time; UpdateTime is stamped here rather than
+// trusting the caller to set it.
+func (c *DebugProfileColl) Update(projectName, name string, profile *models.DebugProfile) error {
+	profile.UpdateTime = time.Now().Unix()
+
+	_, err := c.Collection.UpdateOne(context.Background(),
+		bson.M{"name": name, "project_name": projectName},
+		bson.M{"$set": bson.M{
+			"image":                       profile.Image,
+			"command":                     profile.Command,
+			"args":                        profile.Args,
+			"capabilities":                profile.Capabilities,
+			"privileged":                  profile.Privileged,
+			"share_process_namespace":     profile.ShareProcessNamespace,
+			"target_container_by_default": profile.TargetContainerByDefault,
+			"description":                 profile.Description,
+			"update_by":                   profile.UpdateBy,
+			"update_time":                 profile.UpdateTime,
+		}},
+	)
+	return err
+}
+
+func (c *DebugProfileColl) Delete(projectName, name string) error {
+	_, err := c.Collection.DeleteOne(context.Background(), bson.M{"name": name, "project_name": projectName})
+	return err
+}
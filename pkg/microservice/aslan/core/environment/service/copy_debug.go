@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+// debugCopyPodNamePrefix is used to name pods created by debugByCopyPod so
+// they can be recognized and swept up later.
+const debugCopyPodNamePrefix = "zadig-debug-"
+
+// debugCopyPodTTL bounds how long a copy pod from debugByCopyPod is kept
+// around before sweepExpiredCopyPods deletes it. Copy pods are standalone
+// objects with no owner, so nothing else ever garbage-collects them.
+const debugCopyPodTTL = 4 * time.Hour
+
+// debugByCopyPod implements `kubectl debug --copy-to` semantics for clusters
+// that don't allow ephemeral containers at all: it deep-copies pod, strips
+// the fields that can't be reused on a new object, appends debugContainer as
+// a regular container, and creates the copy alongside the original. The
+// returned pod's name should be used by exec/log APIs instead of the
+// original pod's, since the debug container only exists on the copy.
+func debugByCopyPod(ctx context.Context, podClient corev1client.CoreV1Interface, pod *corev1.Pod,
+	debugContainer *corev1.EphemeralContainer, shareProcessNamespace bool) (*corev1.Pod, error) {
+
+	sweepExpiredCopyPods(ctx, podClient, pod.Namespace)
+
+	copyPod := pod.DeepCopy()
+	copyPod.Name = debugCopyPodNamePrefix + pod.Name + "-" + utilrand.String(5)
+	copyPod.ResourceVersion = ""
+	copyPod.UID = ""
+	copyPod.Status = corev1.PodStatus{}
+	copyPod.Spec.NodeName = ""
+	copyPod.OwnerReferences = nil
+	copyPod.Spec.ShareProcessNamespace = &shareProcessNamespace
+
+	copyPod.Spec.Containers = append(copyPod.Spec.Containers, corev1.Container{
+		Name:                     debugContainer.Name,
+		Image:                    debugContainer.Image,
+		Command:                  debugContainer.Command,
+		Args:                     debugContainer.Args,
+		Env:                      debugContainer.Env,
+		SecurityContext:          debugContainer.SecurityContext,
+		VolumeMounts:             debugContainer.VolumeMounts,
+		ImagePullPolicy:          debugContainer.ImagePullPolicy,
+		TerminationMessagePolicy: debugContainer.TerminationMessagePolicy,
+	})
+
+	newPod, err := podClient.Pods(pod.Namespace).Create(ctx, copyPod, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create debug copy of pod %q: %s", pod.Name, err)
+	}
+
+	return newPod, nil
+}
+
+// cleanupCopyPod deletes a pod previously created by debugByCopyPod. Unlike
+// ephemeral containers, copied pods are standalone objects with no owner and
+// are not garbage-collected automatically, so callers (or a TTL sweep) must
+// delete them explicitly once the debug session is done.
+func cleanupCopyPod(ctx context.Context, podClient corev1client.CoreV1Interface, namespace, podName string) error {
+	err := podClient.Pods(namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to delete debug copy pod %q: %s", podName, err)
+	}
+	return nil
+}
+
+// sweepExpiredCopyPods deletes every copy pod in namespace older than
+// debugCopyPodTTL. It runs opportunistically whenever a new copy pod is
+// about to be created, since this package has no scheduler of its own to
+// run it on a timer. Listing/deleting failures are logged and otherwise
+// ignored: a stuck sweep must never block the caller from getting its own
+// copy pod.
+func sweepExpiredCopyPods(ctx context.Context, podClient corev1client.CoreV1Interface, namespace string) {
+	pods, err := podClient.Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Warnf("failed to list pods in ns %q while sweeping expired debug copy pods: %s", namespace, err)
+		return
+	}
+
+	for _, p := range pods.Items {
+		if !strings.HasPrefix(p.Name, debugCopyPodNamePrefix) {
+			continue
+		}
+		if time.Since(p.CreationTimestamp.Time) < debugCopyPodTTL {
+			continue
+		}
+		if err := cleanupCopyPod(ctx, podClient, namespace, p.Name); err != nil {
+			log.Warnf("failed to sweep expired debug copy pod: %s", err)
+		}
+	}
+}
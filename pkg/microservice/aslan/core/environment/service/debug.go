@@ -20,14 +20,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/apimachinery/pkg/version"
-	"k8s.io/client-go/discovery"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -39,13 +38,56 @@ import (
 const ZadigDebugContainerName = "zadig-debug"
 const K8sBetaVersionForEphemeralContainer = "v1.23"
 
-func PatchDebugContainer(ctx context.Context, projectName, envName, podName, debugImage string) error {
+// DebugContainerOptions customizes the debug container PatchDebugContainer
+// injects. Image is the only required field; everything else defaults to
+// the same bare `tail -f /dev/null` behavior the package has always had.
+type DebugContainerOptions struct {
+	Image string
+
+	// TargetContainer names the existing container in the pod whose process
+	// namespace the debug container should be able to see (via
+	// EphemeralContainer.TargetContainerName and /proc/<pid>/root), matching
+	// `kubectl debug --target`.
+	TargetContainer string
+
+	// Command/Args override the default `tail -f /dev/null`.
+	Command []string
+	Args    []string
+	Env     []corev1.EnvVar
+
+	// SecurityContext lets callers request privileged mode or extra
+	// capabilities (e.g. SYS_PTRACE, SYS_ADMIN) needed for strace/gdb/nsenter
+	// workflows. Left nil, the debug container gets no special privileges.
+	SecurityContext *corev1.SecurityContext
+
+	// InheritVolumeMounts copies TargetContainer's volume mounts onto the
+	// debug container so it can read the target's filesystem directly.
+	InheritVolumeMounts bool
+
+	// ShareProcessNamespace only applies to the debugByCopyPod fallback path,
+	// since ephemeral containers already share the pod's process namespace
+	// by default. It mirrors `kubectl debug --share-processes`.
+	ShareProcessNamespace bool
+}
+
+// PatchDebugContainer injects the debug container described by profileName
+// (curated via the debug profile CRUD endpoints, e.g. "netshoot"/"jvm") into
+// podName, applying overrides on top of the profile, and returns the name of
+// the pod that actually carries it: podName itself when an ephemeral
+// container could be attached in place, or a freshly created copy pod's name
+// when the cluster doesn't support ephemeral containers at all.
+func PatchDebugContainer(ctx context.Context, projectName, envName, podName, profileName string, overrides *DebugProfileOverrides) (string, error) {
+	opts, err := resolveDebugContainerOptions(projectName, profileName, overrides)
+	if err != nil {
+		return "", err
+	}
+
 	prod, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
 		Name:    projectName,
 		EnvName: envName,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to query env %q in project %q: %s", envName, projectName, err)
+		return "", fmt.Errorf("failed to query env %q in project %q: %s", envName, projectName, err)
 	}
 
 	clusterID := prod.ClusterID
@@ -53,22 +95,12 @@ func PatchDebugContainer(ctx context.Context, projectName, envName, podName, deb
 
 	kclient, err := kubeclient.GetKubeClient(config.HubServerAddress(), clusterID)
 	if err != nil {
-		return fmt.Errorf("failed to get kube client: %s", err)
+		return "", fmt.Errorf("failed to get kube client: %s", err)
 	}
 
 	clientset, err := kubeclient.GetKubeClientSet(config.HubServerAddress(), clusterID)
 	if err != nil {
-		return fmt.Errorf("failed to get kube clientset: %s", err)
-	}
-
-	restConfig, err := kubeclient.GetRESTConfig(config.HubServerAddress(), clusterID)
-	if err != nil {
-		return fmt.Errorf("failed to get rest config: %s", err)
-	}
-
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
-	if err != nil {
-		return fmt.Errorf("failed to get discovery client: %s", err)
+		return "", fmt.Errorf("failed to get kube clientset: %s", err)
 	}
 
 	pod := &corev1.Pod{}
@@ -77,49 +109,78 @@ func PatchDebugContainer(ctx context.Context, projectName, envName, podName, deb
 		Namespace: ns,
 	}, pod)
 	if err != nil {
-		return fmt.Errorf("failed to get pod %q in ns %q: %s", podName, ns, err)
+		return "", fmt.Errorf("failed to get pod %q in ns %q: %s", podName, ns, err)
 	}
 
-	k8sVersion, err := checkK8sVersion(discoveryClient)
+	k8sVersion, err := kubeclient.GetCachedServerVersion(config.HubServerAddress(), clusterID)
 	if err != nil {
-		return fmt.Errorf("failed to check K8s version: %s", err)
+		return "", fmt.Errorf("failed to check K8s version: %s", err)
 	}
 
-	debugContainer := genDebugContainer(debugImage)
+	debugContainer := genDebugContainer(opts, pod)
 	if version.CompareKubeAwareVersionStrings(K8sBetaVersionForEphemeralContainer, k8sVersion) < 0 {
 		_, _, err = debugByEphemeralContainerLegacy(ctx, clientset.CoreV1(), pod, debugContainer)
 	} else {
 		_, _, err = debugByEphemeralContainer(ctx, clientset.CoreV1(), pod, debugContainer)
 	}
 
-	return err
-}
-
-func checkK8sVersion(client *discovery.DiscoveryClient) (string, error) {
-	serverInfo, err := client.ServerVersion()
-	if err != nil {
-		return "", err
+	if isEphemeralContainerDisabled(err) {
+		var copyPod *corev1.Pod
+		copyPod, err = debugByCopyPod(ctx, clientset.CoreV1(), pod, debugContainer, opts.ShareProcessNamespace)
+		if err != nil {
+			return "", err
+		}
+		return copyPod.Name, nil
 	}
 
-	// Examples: v1.23.3, v1.20.6-tke.16
-	items := strings.Split(serverInfo.GitVersion, ".")
-	if len(items) < 2 {
-		return "", fmt.Errorf("invalid server version format %q", serverInfo.GitVersion)
-	}
+	return podName, err
+}
 
-	return fmt.Sprintf("%s.%s", items[0], items[1]), nil
+// isEphemeralContainerDisabled reports whether err indicates the cluster
+// rejected the ephemeral container patch because the feature itself is
+// unavailable (feature gate off, or a vendor distribution such as TKE's
+// v1.20.6-tke.16 that blocks the subresource outright), as opposed to some
+// other failure we should just surface to the caller. A 404 is deliberately
+// not treated as "disabled": it more plausibly means the pod was
+// deleted/evicted between the earlier Get and this patch, and falling back
+// to a copy of the now-stale in-memory pod would be wrong.
+func isEphemeralContainerDisabled(err error) bool {
+	if err == nil {
+		return false
+	}
+	return apierrors.IsForbidden(err) || apierrors.IsMethodNotSupported(err)
 }
 
-func genDebugContainer(imageName string) *corev1.EphemeralContainer {
-	return &corev1.EphemeralContainer{
+func genDebugContainer(opts *DebugContainerOptions, pod *corev1.Pod) *corev1.EphemeralContainer {
+	command := opts.Command
+	if len(command) == 0 {
+		command = []string{"tail", "-f", "/dev/null"}
+	}
+
+	container := &corev1.EphemeralContainer{
 		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
 			Name:                     ZadigDebugContainerName,
-			Image:                    imageName,
-			Command:                  []string{"tail", "-f", "/dev/null"},
+			Image:                    opts.Image,
+			Command:                  command,
+			Args:                     opts.Args,
+			Env:                      opts.Env,
+			SecurityContext:          opts.SecurityContext,
 			ImagePullPolicy:          corev1.PullAlways,
 			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
 		},
+		TargetContainerName: opts.TargetContainer,
 	}
+
+	if opts.InheritVolumeMounts && opts.TargetContainer != "" {
+		for _, c := range pod.Spec.Containers {
+			if c.Name == opts.TargetContainer {
+				container.VolumeMounts = c.VolumeMounts
+				break
+			}
+		}
+	}
+
+	return container
 }
 
 func debugByEphemeralContainerLegacy(ctx context.Context, podClient corev1client.CoreV1Interface, pod *corev1.Pod,
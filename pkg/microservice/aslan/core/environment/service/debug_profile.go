@@ -0,0 +1,200 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+)
+
+// Built-in profile names, mirroring the short-list kubectl-debug and similar
+// tools ship out of the box. They're global (ProjectName == ""), visible to
+// every project; projects curate their own on top of these through the
+// profile CRUD endpoints. EnsureDefaultDebugProfiles seeds them, lazily via
+// resolveDebugContainerOptions.
+const (
+	DebugProfileGeneral  = "general"
+	DebugProfileNetshoot = "netshoot"
+	DebugProfileJVM      = "jvm"
+	DebugProfileGolang   = "golang"
+)
+
+// defaultDebugProfiles are the profiles EnsureDefaultDebugProfiles seeds.
+var defaultDebugProfiles = []*models.DebugProfile{
+	{
+		Name:        DebugProfileGeneral,
+		Image:       "busybox:latest",
+		Description: "Bare busybox shell for poking around a pod's network/filesystem.",
+	},
+	{
+		Name:         DebugProfileNetshoot,
+		Image:        "nicolaka/netshoot:latest",
+		Capabilities: []string{"NET_ADMIN", "NET_RAW"},
+		Description:  "tcpdump/iproute2/dig and friends for chasing down network issues.",
+	},
+	{
+		Name:                     DebugProfileJVM,
+		Image:                    "koderover/debug-jvm-tools:latest",
+		TargetContainerByDefault: true,
+		Description:              "arthas/jstack/jmap against a running JVM; requires a target container.",
+	},
+	{
+		Name:                     DebugProfileGolang,
+		Image:                    "koderover/debug-delve:latest",
+		Command:                  []string{"dlv"},
+		Args:                     []string{"attach", "1"},
+		TargetContainerByDefault: true,
+		Description:              "delve attached to the target container's main process.",
+	},
+}
+
+// EnsureDefaultDebugProfiles creates the built-in profiles (general,
+// netshoot, jvm, golang) that don't already exist, leaving any profile an
+// operator has since edited untouched. A built-in profile that has been
+// deleted is created again. Safe to call repeatedly.
+// There's no startup/migration step in this build to call it from, so
+// resolveDebugContainerOptions also calls it lazily the first time a lookup
+// comes back empty.
+func EnsureDefaultDebugProfiles() error {
+	for _, p := range defaultDebugProfiles {
+		_, err := commonrepo.NewDebugProfileColl().Find("", p.Name)
+		if err == nil {
+			continue
+		}
+		if err != mongo.ErrNoDocuments {
+			return fmt.Errorf("failed to check existing debug profile %q: %s", p.Name, err)
+		}
+
+		profile := *p
+		if err := CreateDebugProfile(&profile); err != nil {
+			return fmt.Errorf("failed to seed debug profile %q: %s", p.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// DebugProfileOverrides lets a caller that picked a profile still tweak a
+// handful of per-session fields without forking the whole profile. Zero
+// values mean "use whatever the profile says".
+type DebugProfileOverrides struct {
+	TargetContainer string
+	Command         []string
+	Args            []string
+	Env             []corev1.EnvVar
+}
+
+// CreateDebugProfile persists a new named debug profile scoped to
+// profile.ProjectName.
+func CreateDebugProfile(profile *models.DebugProfile) error {
+	return commonrepo.NewDebugProfileColl().Create(profile)
+}
+
+// ListDebugProfiles returns every profile curated for projectName, plus the
+// global built-in profiles every project can see.
+func ListDebugProfiles(projectName string) ([]*models.DebugProfile, error) {
+	return commonrepo.NewDebugProfileColl().List(projectName)
+}
+
+// UpdateDebugProfile replaces projectName's named profile's fields.
+func UpdateDebugProfile(projectName, name string, profile *models.DebugProfile) error {
+	return commonrepo.NewDebugProfileColl().Update(projectName, name, profile)
+}
+
+// DeleteDebugProfile removes projectName's named profile. A global profile of
+// the same name stays selectable for the project, and a deleted built-in
+// profile is seeded again by the next lookup that finds no match.
+func DeleteDebugProfile(projectName, name string) error {
+	return commonrepo.NewDebugProfileColl().Delete(projectName, name)
+}
+
+// resolveDebugContainerOptions looks up profileName (scoped to projectName,
+// falling back to the global built-ins) and applies overrides on top of it,
+// producing the DebugContainerOptions PatchDebugContainer actually acts on.
+func resolveDebugContainerOptions(projectName, profileName string, overrides *DebugProfileOverrides) (*DebugContainerOptions, error) {
+	profile, err := commonrepo.NewDebugProfileColl().Find(projectName, profileName)
+	if err == mongo.ErrNoDocuments {
+		// Nothing seeds the built-ins ahead of time, so the first lookup
+		// after a fresh deploy seeds them and retries once.
+		if seedErr := EnsureDefaultDebugProfiles(); seedErr != nil {
+			return nil, fmt.Errorf("failed to seed default debug profiles: %s", seedErr)
+		}
+		profile, err = commonrepo.NewDebugProfileColl().Find(projectName, profileName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find debug profile %q: %s", profileName, err)
+	}
+
+	return buildDebugContainerOptions(profile, overrides)
+}
+
+// buildDebugContainerOptions applies overrides on top of profile, producing
+// the DebugContainerOptions PatchDebugContainer actually acts on. Split out
+// from resolveDebugContainerOptions so this validation/override logic can be
+// unit tested without a Mongo profile lookup.
+func buildDebugContainerOptions(profile *models.DebugProfile, overrides *DebugProfileOverrides) (*DebugContainerOptions, error) {
+	opts := &DebugContainerOptions{
+		Image:                 profile.Image,
+		Command:               profile.Command,
+		Args:                  profile.Args,
+		ShareProcessNamespace: profile.ShareProcessNamespace,
+		InheritVolumeMounts:   profile.TargetContainerByDefault,
+	}
+
+	if len(profile.Capabilities) > 0 || profile.Privileged {
+		opts.SecurityContext = &corev1.SecurityContext{
+			Privileged: &profile.Privileged,
+			Capabilities: &corev1.Capabilities{
+				Add: toCapabilities(profile.Capabilities),
+			},
+		}
+	}
+
+	if overrides != nil && overrides.TargetContainer != "" {
+		opts.TargetContainer = overrides.TargetContainer
+		opts.InheritVolumeMounts = true
+	} else if profile.TargetContainerByDefault {
+		return nil, fmt.Errorf("debug profile %q requires a target container but none was given", profile.Name)
+	}
+
+	if overrides != nil {
+		if len(overrides.Command) > 0 {
+			opts.Command = overrides.Command
+		}
+		if len(overrides.Args) > 0 {
+			opts.Args = overrides.Args
+		}
+		if len(overrides.Env) > 0 {
+			opts.Env = overrides.Env
+		}
+	}
+
+	return opts, nil
+}
+
+func toCapabilities(names []string) []corev1.Capability {
+	caps := make([]corev1.Capability, len(names))
+	for i, name := range names {
+		caps[i] = corev1.Capability(name)
+	}
+	return caps
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"testing"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func TestBuildDebugContainerOptionsNoOverrides(t *testing.T) {
+	profile := &models.DebugProfile{
+		Name:    "netshoot",
+		Image:   "nicolaka/netshoot:latest",
+		Command: []string{"bash"},
+	}
+
+	opts, err := buildDebugContainerOptions(profile, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if opts.Image != profile.Image {
+		t.Errorf("Image = %q, want %q", opts.Image, profile.Image)
+	}
+	if opts.TargetContainer != "" {
+		t.Errorf("TargetContainer = %q, want empty", opts.TargetContainer)
+	}
+}
+
+func TestBuildDebugContainerOptionsTargetContainerRequired(t *testing.T) {
+	profile := &models.DebugProfile{
+		Name:                     "jvm",
+		Image:                    "koderover/debug-jvm-tools:latest",
+		TargetContainerByDefault: true,
+	}
+
+	// Nil overrides: the profile requires a target container but none was given.
+	if _, err := buildDebugContainerOptions(profile, nil); err == nil {
+		t.Fatal("expected an error when overrides is nil and a target container is required")
+	}
+
+	// Non-nil overrides that don't set TargetContainer must still trip the
+	// same validation - this is the bug the prior fix addressed.
+	if _, err := buildDebugContainerOptions(profile, &DebugProfileOverrides{Command: []string{"sh"}}); err == nil {
+		t.Fatal("expected an error when overrides.TargetContainer is unset")
+	}
+
+	opts, err := buildDebugContainerOptions(profile, &DebugProfileOverrides{TargetContainer: "app"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if opts.TargetContainer != "app" {
+		t.Errorf("TargetContainer = %q, want %q", opts.TargetContainer, "app")
+	}
+	if !opts.InheritVolumeMounts {
+		t.Error("expected InheritVolumeMounts to be true once a target container is set")
+	}
+}
+
+func TestBuildDebugContainerOptionsOverridesApply(t *testing.T) {
+	profile := &models.DebugProfile{
+		Name:    "general",
+		Image:   "busybox:latest",
+		Command: []string{"tail", "-f", "/dev/null"},
+	}
+
+	opts, err := buildDebugContainerOptions(profile, &DebugProfileOverrides{
+		Command: []string{"sh", "-c", "sleep 3600"},
+		Args:    []string{"extra"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(opts.Command) != 3 || opts.Command[0] != "sh" {
+		t.Errorf("Command = %v, want override to take effect", opts.Command)
+	}
+	if len(opts.Args) != 1 || opts.Args[0] != "extra" {
+		t.Errorf("Args = %v, want override to take effect", opts.Args)
+	}
+}
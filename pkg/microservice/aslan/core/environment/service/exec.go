@@ -0,0 +1,237 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	kubeclient "github.com/koderover/zadig/pkg/shared/kube/client"
+)
+
+// waitForDebugContainerRunningTimeout bounds how long we wait for the debug
+// container to leave the Waiting state before attaching, since
+// PatchDebugContainer returns as soon as the patch/create is accepted and
+// the kubelet has not necessarily pulled/started the container yet.
+const waitForDebugContainerRunningTimeout = 2 * time.Minute
+
+// resizeMessage is the JSON payload the frontend terminal sends whenever
+// the user resizes their browser window.
+type resizeMessage struct {
+	Cols uint16 `json:"cols"`
+	Rows uint16 `json:"rows"`
+}
+
+// wsTerminalSession adapts a websocket connection to the
+// remotecommand.TerminalSizeQueue / io.ReadWriter interfaces that
+// client-go's SPDY executor streams against, so stdin/stdout/resize all
+// multiplex over the single connection the UI opens.
+type wsTerminalSession struct {
+	conn     *websocket.Conn
+	sizeChan chan remotecommand.TerminalSize
+
+	// pending holds the tail of a websocket frame that didn't fit in the last
+	// Read call's buffer, so it can be handed out on the next Read instead of
+	// being silently dropped.
+	pending []byte
+}
+
+func newWSTerminalSession(conn *websocket.Conn) *wsTerminalSession {
+	return &wsTerminalSession{
+		conn:     conn,
+		sizeChan: make(chan remotecommand.TerminalSize),
+	}
+}
+
+// Next implements remotecommand.TerminalSizeQueue.
+func (t *wsTerminalSession) Next() *remotecommand.TerminalSize {
+	size, ok := <-t.sizeChan
+	if !ok {
+		return nil
+	}
+	return &size
+}
+
+// Read implements io.Reader, feeding stdin bytes read off the websocket to
+// the remote command's stdin stream. Resize messages arriving on the same
+// connection are intercepted and routed to sizeChan instead of being
+// forwarded as stdin. A frame larger than p is buffered in pending and
+// drained across subsequent calls instead of being truncated.
+func (t *wsTerminalSession) Read(p []byte) (int, error) {
+	if len(t.pending) > 0 {
+		n := copy(p, t.pending)
+		t.pending = t.pending[n:]
+		return n, nil
+	}
+
+	for {
+		msgType, data, err := t.conn.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+
+		if msgType != websocket.TextMessage && msgType != websocket.BinaryMessage {
+			continue
+		}
+
+		var resize resizeMessage
+		if err := json.Unmarshal(data, &resize); err == nil && resize.Cols > 0 && resize.Rows > 0 {
+			t.sizeChan <- remotecommand.TerminalSize{Width: resize.Cols, Height: resize.Rows}
+			continue
+		}
+
+		n := copy(p, data)
+		if n < len(data) {
+			t.pending = data[n:]
+		}
+		return n, nil
+	}
+}
+
+// Write implements io.Writer, forwarding the remote command's stdout/stderr
+// back to the UI as websocket frames.
+func (t *wsTerminalSession) Write(p []byte) (int, error) {
+	if err := t.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (t *wsTerminalSession) Close() {
+	close(t.sizeChan)
+	t.conn.Close()
+}
+
+// ExecDebugContainer attaches an interactive shell to the debug container
+// previously injected by PatchDebugContainer, streaming stdin/stdout/stderr
+// and TTY resize events over the given websocket connection until either
+// side closes the connection or the remote command exits. Callers are
+// expected to have already upgraded the HTTP request to a websocket before
+// invoking this.
+func ExecDebugContainer(ctx context.Context, projectName, envName, podName string, conn *websocket.Conn) error {
+	prod, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
+		Name:    projectName,
+		EnvName: envName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query env %q in project %q: %s", envName, projectName, err)
+	}
+
+	clusterID := prod.ClusterID
+	ns := prod.Namespace
+
+	clientset, err := kubeclient.GetKubeClientSet(config.HubServerAddress(), clusterID)
+	if err != nil {
+		return fmt.Errorf("failed to get kube clientset: %s", err)
+	}
+
+	restConfig, err := kubeclient.GetRESTConfig(config.HubServerAddress(), clusterID)
+	if err != nil {
+		return fmt.Errorf("failed to get rest config: %s", err)
+	}
+
+	if err := waitForDebugContainerRunning(ctx, clientset.CoreV1(), ns, podName, ZadigDebugContainerName); err != nil {
+		return fmt.Errorf("debug container did not become ready: %s", err)
+	}
+
+	req := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(ns).
+		SubResource("exec")
+	req.VersionedParams(&corev1.PodExecOptions{
+		Container: ZadigDebugContainerName,
+		Command:   []string{"sh"},
+		Stdin:     true,
+		Stdout:    true,
+		Stderr:    true,
+		TTY:       true,
+	}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(restConfig, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to create SPDY executor: %s", err)
+	}
+
+	session := newWSTerminalSession(conn)
+	defer session.Close()
+
+	return executor.Stream(remotecommand.StreamOptions{
+		Stdin:             session,
+		Stdout:            session,
+		Stderr:            session,
+		TerminalSizeQueue: session,
+		Tty:               true,
+	})
+}
+
+// waitForDebugContainerRunning polls the pod until containerName reports
+// Running, since a successful patch/create only means the kubelet accepted
+// the spec change, not that the container image has been pulled and started
+// yet. containerName shows up under EphemeralContainerStatuses when it was
+// attached via PatchDebugContainer's ephemeral-container path, but under the
+// plain ContainerStatuses when it came from the copy-pod fallback (there
+// it's just a regular container on a regular pod), so both are checked.
+func waitForDebugContainerRunning(ctx context.Context, podClient corev1client.CoreV1Interface, namespace, podName, containerName string) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, waitForDebugContainerRunningTimeout)
+	defer cancel()
+
+	return wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		pod, err := podClient.Pods(namespace).Get(timeoutCtx, podName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		for _, status := range pod.Status.EphemeralContainerStatuses {
+			if status.Name != containerName {
+				continue
+			}
+			if status.State.Running != nil {
+				return true, nil
+			}
+			if status.State.Terminated != nil {
+				return false, fmt.Errorf("debug container %q terminated: %s", containerName, status.State.Terminated.Reason)
+			}
+		}
+
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != containerName {
+				continue
+			}
+			if status.State.Running != nil {
+				return true, nil
+			}
+			if status.State.Terminated != nil {
+				return false, fmt.Errorf("debug container %q terminated: %s", containerName, status.State.Terminated.Reason)
+			}
+		}
+
+		return false, nil
+	}, timeoutCtx.Done())
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestWSTerminalSessionReadBuffersOversizedFrame exercises only the pending
+// path of wsTerminalSession.Read (no websocket conn involved): once a frame
+// is wider than the caller's buffer, the remainder must come out on the next
+// Read call instead of being dropped on the floor.
+func TestWSTerminalSessionReadBuffersOversizedFrame(t *testing.T) {
+	session := &wsTerminalSession{pending: []byte("hello world")}
+
+	var got bytes.Buffer
+	buf := make([]byte, 4)
+	for len(session.pending) > 0 {
+		n, err := session.Read(buf)
+		if err != nil {
+			t.Fatalf("unexpected error draining pending: %s", err)
+		}
+		if n == 0 {
+			t.Fatalf("Read returned 0 bytes with pending data still buffered")
+		}
+		got.Write(buf[:n])
+	}
+
+	if got.String() != "hello world" {
+		t.Fatalf("drained %q, want %q", got.String(), "hello world")
+	}
+}
+
+func TestWSTerminalSessionReadExactFitLeavesNoPending(t *testing.T) {
+	session := &wsTerminalSession{pending: []byte("1234")}
+
+	buf := make([]byte, 4)
+	n, err := session.Read(buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 4 || string(buf[:n]) != "1234" {
+		t.Fatalf("got (%d, %q), want (4, %q)", n, buf[:n], "1234")
+	}
+	if len(session.pending) != 0 {
+		t.Fatalf("expected pending to be fully drained, got %d bytes left", len(session.pending))
+	}
+}
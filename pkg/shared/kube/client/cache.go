@@ -0,0 +1,248 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package client builds and caches the per-cluster clients aslan uses to
+// talk to the clusters it manages. Building a REST config, a typed
+// clientset and a discovery client involves a round-trip through the hub
+// server proxy on every call; callers that do this on every request (debug
+// container patches, workload listings, etc.) end up hammering the API
+// server just to re-derive state that rarely changes. GetKubeClient,
+// GetKubeClientSet, GetRESTConfig and GetCachedDiscoveryClient all share a
+// single cache entry per clusterID instead.
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+	memcached "k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clientCacheTTL bounds how long a cached cluster client is reused before
+// being rebuilt from scratch, so stale credentials or a rotated hub agent
+// token eventually get picked up even without an explicit invalidation.
+const clientCacheTTL = 10 * time.Minute
+
+// serverVersionCacheTTL bounds how long a cached server version is reused.
+// It doesn't need to track clientCacheTTL: the version only moves when the
+// cluster itself is upgraded, far less often than credentials rotate, but we
+// still want an eventual refresh instead of caching it forever.
+const serverVersionCacheTTL = 10 * time.Minute
+
+type clusterClients struct {
+	restConfig      *rest.Config
+	clientset       *kubernetes.Clientset
+	runtimeClient   client.Client
+	discoveryClient discovery.CachedDiscoveryInterface
+	cachedAt        time.Time
+
+	versionMu             sync.Mutex
+	serverVersion         string
+	serverVersionCachedAt time.Time
+}
+
+func (c *clusterClients) expired() bool {
+	return time.Since(c.cachedAt) > clientCacheTTL
+}
+
+// cachedServerVersion returns the cached server version and true if one was
+// fetched within serverVersionCacheTTL. Callers must hold c.versionMu.
+func (c *clusterClients) cachedServerVersion() (string, bool) {
+	if c.serverVersion == "" {
+		return "", false
+	}
+	if time.Since(c.serverVersionCachedAt) > serverVersionCacheTTL {
+		return "", false
+	}
+	return c.serverVersion, true
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]*clusterClients{}
+)
+
+// GetRESTConfig returns a cached *rest.Config for clusterID, building and
+// caching one via the hub server proxy if none exists yet.
+func GetRESTConfig(hubServerAddr, clusterID string) (*rest.Config, error) {
+	entry, err := getOrBuild(hubServerAddr, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return entry.restConfig, nil
+}
+
+// GetKubeClientSet returns a cached typed clientset for clusterID.
+func GetKubeClientSet(hubServerAddr, clusterID string) (*kubernetes.Clientset, error) {
+	entry, err := getOrBuild(hubServerAddr, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return entry.clientset, nil
+}
+
+// GetKubeClient returns a cached controller-runtime client for clusterID.
+func GetKubeClient(hubServerAddr, clusterID string) (client.Client, error) {
+	entry, err := getOrBuild(hubServerAddr, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return entry.runtimeClient, nil
+}
+
+// GetCachedDiscoveryClient returns a memory-cached discovery client for
+// clusterID. Call InvalidateCachedDiscoveryClient for this clusterID after a
+// call against it fails with a 404 or GroupDiscoveryFailed error, since
+// those typically mean the cached API group/resource list is stale.
+func GetCachedDiscoveryClient(hubServerAddr, clusterID string) (discovery.CachedDiscoveryInterface, error) {
+	entry, err := getOrBuild(hubServerAddr, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return entry.discoveryClient, nil
+}
+
+// GetCachedServerVersion returns clusterID's "major.minor" server version
+// (e.g. "v1.23"), fetching it from the discovery client at most once per
+// serverVersionCacheTTL instead of round-tripping to the API server on every
+// call. Unlike GetCachedDiscoveryClient, this caches the version itself:
+// client-go's memory-cached discovery client only memoizes
+// ServerGroups/ServerGroupsAndResources/ServerResourcesForGroupVersion, and
+// passes ServerVersion straight through to the underlying client uncached.
+func GetCachedServerVersion(hubServerAddr, clusterID string) (string, error) {
+	entry, err := getOrBuild(hubServerAddr, clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	entry.versionMu.Lock()
+	defer entry.versionMu.Unlock()
+
+	if cached, ok := entry.cachedServerVersion(); ok {
+		return cached, nil
+	}
+
+	serverInfo, err := entry.discoveryClient.ServerVersion()
+	if err != nil {
+		return "", err
+	}
+
+	majorMinor, err := majorMinorVersion(serverInfo.GitVersion)
+	if err != nil {
+		return "", err
+	}
+
+	entry.serverVersion = majorMinor
+	entry.serverVersionCachedAt = time.Now()
+
+	return entry.serverVersion, nil
+}
+
+// majorMinorVersion extracts "major.minor" out of a GitVersion string such
+// as "v1.23.3" or "v1.20.6-tke.16", returning "v1.23"/"v1.20" respectively.
+func majorMinorVersion(gitVersion string) (string, error) {
+	items := strings.Split(gitVersion, ".")
+	if len(items) < 2 {
+		return "", fmt.Errorf("invalid server version format %q", gitVersion)
+	}
+	return fmt.Sprintf("%s.%s", items[0], items[1]), nil
+}
+
+// InvalidateCachedDiscoveryClient drops the discovery cache for clusterID so
+// the next GetCachedDiscoveryClient call re-fetches server groups/resources.
+// It does not evict the REST config/clientset/runtime client, which aren't
+// affected by stale discovery data.
+func InvalidateCachedDiscoveryClient(clusterID string) {
+	cacheMu.Lock()
+	entry, ok := cache[clusterID]
+	cacheMu.Unlock()
+	if ok {
+		entry.discoveryClient.Invalidate()
+	}
+}
+
+// InvalidateClusterCache evicts every cached client for clusterID, forcing
+// the next call to rebuild everything from scratch.
+func InvalidateClusterCache(clusterID string) {
+	cacheMu.Lock()
+	delete(cache, clusterID)
+	cacheMu.Unlock()
+}
+
+// IsStaleDiscoveryError reports whether err looks like it was caused by a
+// stale discovery cache (a 404 against a resource that used to exist, or the
+// aggregated GroupDiscoveryFailedError client-go returns when some API group
+// couldn't be reached), as opposed to a real, persistent failure.
+func IsStaleDiscoveryError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.IsNotFound(err) {
+		return true
+	}
+	_, ok := err.(*discovery.ErrGroupDiscoveryFailed)
+	return ok
+}
+
+func getOrBuild(hubServerAddr, clusterID string) (*clusterClients, error) {
+	cacheMu.Lock()
+	entry, ok := cache[clusterID]
+	cacheMu.Unlock()
+	if ok && !entry.expired() {
+		return entry, nil
+	}
+
+	restConfig, err := buildRESTConfig(hubServerAddr, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	runtimeClient, err := client.New(restConfig, client.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	entry = &clusterClients{
+		restConfig:      restConfig,
+		clientset:       clientset,
+		runtimeClient:   runtimeClient,
+		discoveryClient: memcached.NewMemCacheClient(discoveryClient),
+		cachedAt:        time.Now(),
+	}
+
+	cacheMu.Lock()
+	cache[clusterID] = entry
+	cacheMu.Unlock()
+
+	return entry, nil
+}
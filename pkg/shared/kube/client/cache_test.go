@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClusterClientsExpired(t *testing.T) {
+	fresh := &clusterClients{cachedAt: time.Now()}
+	if fresh.expired() {
+		t.Error("freshly cached entry reported expired")
+	}
+
+	stale := &clusterClients{cachedAt: time.Now().Add(-clientCacheTTL - time.Minute)}
+	if !stale.expired() {
+		t.Error("entry past clientCacheTTL reported not expired")
+	}
+}
+
+func TestMajorMinorVersion(t *testing.T) {
+	cases := []struct {
+		gitVersion string
+		want       string
+		wantErr    bool
+	}{
+		{gitVersion: "v1.23.3", want: "v1.23"},
+		{gitVersion: "v1.20.6-tke.16", want: "v1.20"},
+		{gitVersion: "v1", wantErr: true},
+		{gitVersion: "", wantErr: true},
+	}
+
+	for _, c := range cases {
+		got, err := majorMinorVersion(c.gitVersion)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("majorMinorVersion(%q) = %q, want an error", c.gitVersion, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("majorMinorVersion(%q) returned unexpected error: %s", c.gitVersion, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("majorMinorVersion(%q) = %q, want %q", c.gitVersion, got, c.want)
+		}
+	}
+}
+
+func TestClusterClientsCachedServerVersion(t *testing.T) {
+	entry := &clusterClients{}
+	if _, ok := entry.cachedServerVersion(); ok {
+		t.Fatal("an entry with no cached version should report a cache miss")
+	}
+
+	entry.serverVersion = "v1.23"
+	entry.serverVersionCachedAt = time.Now()
+	got, ok := entry.cachedServerVersion()
+	if !ok || got != "v1.23" {
+		t.Fatalf("cachedServerVersion() = (%q, %v), want (\"v1.23\", true)", got, ok)
+	}
+
+	entry.serverVersionCachedAt = time.Now().Add(-serverVersionCacheTTL - time.Minute)
+	if _, ok := entry.cachedServerVersion(); ok {
+		t.Fatal("a version cached past serverVersionCacheTTL should be treated as stale")
+	}
+}
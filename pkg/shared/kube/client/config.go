@@ -0,0 +1,39 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/rest"
+)
+
+// buildRESTConfig builds a *rest.Config that reaches clusterID through the
+// hub server's cluster proxy, which is how aslan talks to clusters whose
+// agents only maintain an outbound tunnel back to the hub server.
+func buildRESTConfig(hubServerAddr, clusterID string) (*rest.Config, error) {
+	if hubServerAddr == "" {
+		return nil, fmt.Errorf("hub server address is empty")
+	}
+	if clusterID == "" {
+		return nil, fmt.Errorf("cluster id is empty")
+	}
+
+	return &rest.Config{
+		Host: fmt.Sprintf("%s/api/cluster/%s/proxy", hubServerAddr, clusterID),
+	}, nil
+}